@@ -0,0 +1,190 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package addrsel provides the address-candidate bookkeeping that package
+// net's dialer uses to implement Happy Eyeballs (RFC 8305), so that custom
+// dialers -- SOCKS, TLS, HTTP proxy, QUIC, and the like -- can get the same
+// ordering guarantees as net.Dial without copy-pasting net's unexported
+// helpers.
+//
+// This package intentionally doesn't import net: net imports addrsel, and
+// an import the other way would cycle. IPAddr and Addr are therefore
+// defined structurally rather than as aliases of net.IPAddr/net.Addr; any
+// *net.TCPAddr, *net.UDPAddr or *net.IPAddr already satisfies Addr, and a
+// net.IPAddr converts to an IPAddr with a one-line literal (as net's
+// filterAndTagAddrs does).
+package addrsel
+
+import "errors"
+
+// IPAddr is an IP address, and optionally the IPv6 zone it's scoped to.
+// IP must be either 4 bytes (an IPv4 address) or 16 bytes (an IPv6
+// address); anything else is treated as unusable by Filter.
+type IPAddr struct {
+	IP   []byte
+	Zone string
+}
+
+// IsIPv4 reports whether addr holds an IPv4 address, whether it's stored
+// as the 4 raw bytes or in 16-byte IPv4-in-IPv6-mapped form
+// (::ffff:a.b.c.d) -- the form callers building an IPAddr from a
+// net.IP via net.IPv4 or a parsed dotted-quad typically get.
+func IsIPv4(addr IPAddr) bool { return len(addr.IP) == 4 || isV4InV6(addr.IP) }
+
+// IsIPv6 reports whether addr holds a 16-byte address that isn't just an
+// IPv4 address in mapped form.
+func IsIPv6(addr IPAddr) bool { return len(addr.IP) == 16 && !isV4InV6(addr.IP) }
+
+// isV4InV6 reports whether ip is a 16-byte IPv4-mapped IPv6 address, i.e.
+// ten zero bytes followed by 0xff, 0xff (RFC 4291 section 2.5.5.2).
+func isV4InV6(ip []byte) bool {
+	if len(ip) != 16 {
+		return false
+	}
+	for _, b := range ip[:10] {
+		if b != 0 {
+			return false
+		}
+	}
+	return ip[10] == 0xff && ip[11] == 0xff
+}
+
+// Addr is the common shape of every net.Addr implementation (net.TCPAddr,
+// net.UDPAddr, net.IPAddr, ...). It's spelled out here, rather than
+// imported, purely to avoid the import cycle described in the package doc.
+type Addr interface {
+	Network() string
+	String() string
+}
+
+// ErrNoSuitableAddress is returned by Filter when, after applying filter,
+// no address remains.
+var ErrNoSuitableAddress = errors.New("addrsel: no suitable address found")
+
+// candidate is one dial candidate produced by Filter.
+type candidate struct {
+	addr     Addr
+	isIPv4   bool
+	single   bool
+	fallback bool
+}
+
+// Candidates is the ordered, tagged result of Filter. It carries enough
+// bookkeeping for a caller to reproduce net.Dial's Happy Eyeballs ordering:
+// Single for legacy APIs that want exactly one address, Primaries/
+// Fallbacks for a two-thread (classic Happy Eyeballs) dialer, and
+// Interleaved for a full RFC 8305 multi-attempt scheduler.
+type Candidates []candidate
+
+// Filter applies filter (nil, or a predicate built from IsIPv4/IsIPv6) to
+// ips, converts the survivors to dial addresses with mk, and tags them for
+// Happy Eyeballs. ips is expected to already be in the caller's preferred
+// order (e.g. sorted per RFC 6724); the first surviving address becomes
+// Single(), and whichever address family didn't win that slot is tagged as
+// the Fallbacks() family. It returns ErrNoSuitableAddress if every address
+// is filtered out.
+func Filter(filter func(IPAddr) bool, ips []IPAddr, mk func(IPAddr) Addr) (Candidates, error) {
+	var (
+		cands Candidates
+		v4Idx []int
+		v6Idx []int
+	)
+	for _, ip := range ips {
+		if filter != nil && !filter(ip) {
+			continue
+		}
+		switch {
+		case IsIPv4(ip):
+			v4Idx = append(v4Idx, len(cands))
+			cands = append(cands, candidate{addr: mk(ip), isIPv4: true})
+		case IsIPv6(ip):
+			v6Idx = append(v6Idx, len(cands))
+			cands = append(cands, candidate{addr: mk(ip), isIPv4: false})
+		}
+	}
+	if len(cands) == 0 {
+		return nil, ErrNoSuitableAddress
+	}
+	cands[0].single = true
+	fallbackIdx := v6Idx
+	if !cands[0].isIPv4 {
+		fallbackIdx = v4Idx
+	}
+	for _, i := range fallbackIdx {
+		cands[i].fallback = true
+	}
+	return cands, nil
+}
+
+// Single returns the one address tagged for legacy APIs that can't handle
+// more than one, or nil if cands is empty.
+func (cands Candidates) Single() Addr {
+	for _, c := range cands {
+		if c.single {
+			return c.addr
+		}
+	}
+	return nil
+}
+
+// All returns every candidate address, in order.
+func (cands Candidates) All() []Addr {
+	out := make([]Addr, len(cands))
+	for i, c := range cands {
+		out[i] = c.addr
+	}
+	return out
+}
+
+// Primaries returns the addresses of the winning (non-fallback) family.
+func (cands Candidates) Primaries() []Addr {
+	var out []Addr
+	for _, c := range cands {
+		if !c.fallback {
+			out = append(out, c.addr)
+		}
+	}
+	return out
+}
+
+// Fallbacks returns the addresses of the family other than the first
+// candidate's.
+func (cands Candidates) Fallbacks() []Addr {
+	var out []Addr
+	for _, c := range cands {
+		if c.fallback {
+			out = append(out, c.addr)
+		}
+	}
+	return out
+}
+
+// Interleaved returns every address, reordered so the two families
+// alternate starting with the family of cands[0] (RFC 8305 section 4). A
+// single-family list is returned unchanged; a family that runs out early
+// is simply skipped for the remaining slots.
+func (cands Candidates) Interleaved() []Addr {
+	if len(cands) == 0 {
+		return nil
+	}
+	firstIsIPv4 := cands[0].isIPv4
+	var primary, secondary []Addr
+	for _, c := range cands {
+		if c.isIPv4 == firstIsIPv4 {
+			primary = append(primary, c.addr)
+		} else {
+			secondary = append(secondary, c.addr)
+		}
+	}
+	out := make([]Addr, 0, len(primary)+len(secondary))
+	for i := 0; i < len(primary) || i < len(secondary); i++ {
+		if i < len(primary) {
+			out = append(out, primary[i])
+		}
+		if i < len(secondary) {
+			out = append(out, secondary[i])
+		}
+	}
+	return out
+}
@@ -0,0 +1,64 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package addrsel
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type testAddr string
+
+func (a testAddr) Network() string { return "test" }
+func (a testAddr) String() string  { return string(a) }
+
+func mkTestAddr(ip IPAddr) Addr {
+	return testAddr(fmt.Sprintf("%v%%%v", ip.IP, ip.Zone))
+}
+
+var v4 = IPAddr{IP: []byte{127, 0, 0, 1}}
+var v6 = IPAddr{IP: make([]byte, 16)}
+
+func TestFilter(t *testing.T) {
+	cands, err := Filter(nil, []IPAddr{v4, v6}, mkTestAddr)
+	if err != nil {
+		t.Fatalf("Filter: %v", err)
+	}
+	if got, want := cands.Single(), mkTestAddr(v4); got != want {
+		t.Errorf("Single() = %v; want %v", got, want)
+	}
+	if got, want := cands.Primaries(), []Addr{mkTestAddr(v4)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Primaries() = %v; want %v", got, want)
+	}
+	if got, want := cands.Fallbacks(), []Addr{mkTestAddr(v6)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Fallbacks() = %v; want %v", got, want)
+	}
+	if got, want := len(cands.All()), 2; got != want {
+		t.Errorf("len(All()) = %v; want %v", got, want)
+	}
+}
+
+func TestFilterNoSuitableAddress(t *testing.T) {
+	if _, err := Filter(nil, nil, mkTestAddr); err != ErrNoSuitableAddress {
+		t.Errorf("Filter(nil ips) error = %v; want %v", err, ErrNoSuitableAddress)
+	}
+	if _, err := Filter(IsIPv4, []IPAddr{v6}, mkTestAddr); err != ErrNoSuitableAddress {
+		t.Errorf("Filter(v6-only, IsIPv4) error = %v; want %v", err, ErrNoSuitableAddress)
+	}
+}
+
+func TestCandidatesInterleaved(t *testing.T) {
+	ips := []IPAddr{v6, v4, v4, v6}
+	cands, err := Filter(nil, ips, mkTestAddr)
+	if err != nil {
+		t.Fatalf("Filter: %v", err)
+	}
+	got := cands.Interleaved()
+	want := []Addr{mkTestAddr(v6), mkTestAddr(v4), mkTestAddr(v6), mkTestAddr(v4)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Interleaved() = %v; want %v", got, want)
+	}
+}
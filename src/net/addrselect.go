@@ -0,0 +1,220 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Destination address selection per RFC 6724.
+
+package net
+
+import "sort"
+
+// sortByRFC6724 sorts the ips in place according to the destination address
+// selection rules in RFC 6724 section 6 that it can evaluate: avoid
+// unusable, prefer matching scope, prefer matching label, prefer higher
+// precedence, prefer smaller scope, then prefer the address with the
+// longest matching prefix against the candidate source address. Rules 3
+// (avoid deprecated), 4 (prefer home over care-of) and 7 (prefer native
+// transport) are not applied, since no lifetime, Mobile IPv6 or tunnel
+// information is available; addresses that tie on every applied rule keep
+// their original, stable order.
+func sortByRFC6724(ips []IPAddr) {
+	if len(ips) < 2 {
+		return
+	}
+	sortByRFC6724withSrcs(ips, srcAddrs(ips))
+}
+
+// sortByRFC6724withSrcs is sortByRFC6724 with the per-destination source
+// addresses passed in rather than discovered via srcAddrs, so the rule
+// interactions in byRFC6724.Less can be exercised with synthetic addresses
+// instead of real kernel routing.
+func sortByRFC6724withSrcs(ips []IPAddr, srcs []IP) {
+	if len(ips) < 2 {
+		return
+	}
+	sort.Stable(&byRFC6724{ips: ips, srcs: srcs})
+}
+
+type byRFC6724 struct {
+	ips  []IPAddr
+	srcs []IP // srcs[i] is the preferred source address for ips[i], or nil
+}
+
+func (s *byRFC6724) Len() int { return len(s.ips) }
+func (s *byRFC6724) Swap(i, j int) {
+	s.ips[i], s.ips[j] = s.ips[j], s.ips[i]
+	s.srcs[i], s.srcs[j] = s.srcs[j], s.srcs[i]
+}
+
+func (s *byRFC6724) Less(i, j int) bool {
+	da, db := s.ips[i], s.ips[j]
+	sa, sb := s.srcs[i], s.srcs[j]
+
+	// Rule 1: Avoid unusable destinations.
+	if usable(da, sa) != usable(db, sb) {
+		return usable(da, sa)
+	}
+
+	// Rule 2: Prefer matching scope.
+	if sa != nil && sb != nil {
+		if ma, mb := scopeOf(da.IP) == scopeOf(sa), scopeOf(db.IP) == scopeOf(sb); ma != mb {
+			return ma
+		}
+	}
+
+	// Rule 3: Avoid deprecated addresses (no source-address lifetime info
+	// is available to us, so this rule is a no-op).
+
+	// Rule 4: Prefer home addresses over care-of addresses (not applicable
+	// without Mobile IPv6 support; no-op).
+
+	// Rule 5: Prefer matching label.
+	if sa != nil && sb != nil {
+		if la, lb := labelOf(da.IP) == labelOf(sa), labelOf(db.IP) == labelOf(sb); la != lb {
+			return la
+		}
+	}
+
+	// Rule 6: Prefer higher precedence.
+	if pa, pb := precedenceOf(da.IP), precedenceOf(db.IP); pa != pb {
+		return pa > pb
+	}
+
+	// Rule 7: Prefer native transport (no tunnel detection available; no-op).
+
+	// Rule 8: Prefer smaller scope.
+	if scopeA, scopeB := scopeOf(da.IP), scopeOf(db.IP); scopeA != scopeB {
+		return scopeA < scopeB
+	}
+
+	// Rule 9: Use the longest matching prefix.
+	if sa != nil && sb != nil {
+		if ca, cb := commonPrefixLen(sa, da.IP), commonPrefixLen(sb, db.IP); ca != cb {
+			return ca > cb
+		}
+	}
+
+	// Rule 10: Leave the order as returned by the resolver.
+	return false
+}
+
+// usable reports whether dst has a usable source address. A destination
+// with no usable source (e.g. no local IPv6 connectivity) sorts last.
+func usable(dst IPAddr, src IP) bool {
+	return src != nil
+}
+
+// ipv6Scope is one of the multicast/unicast scope values from RFC 4007 and
+// RFC 6724 table 3; unicast addresses are mapped onto the scopes they'd use
+// were they multicast, purely for comparison purposes.
+type ipv6Scope uint8
+
+const (
+	scopeInterfaceLocal ipv6Scope = 0x1
+	scopeLinkLocal      ipv6Scope = 0x2
+	scopeSiteLocal      ipv6Scope = 0x5
+	scopeOrgLocal       ipv6Scope = 0x8
+	scopeGlobal         ipv6Scope = 0xe
+)
+
+func scopeOf(ip IP) ipv6Scope {
+	if ip4 := ip.To4(); ip4 != nil {
+		switch {
+		case ip4[0] == 127:
+			return scopeInterfaceLocal
+		case ip4.IsLinkLocalUnicast():
+			return scopeLinkLocal
+		default:
+			return scopeGlobal
+		}
+	}
+	if ip.IsLoopback() {
+		return scopeInterfaceLocal
+	}
+	if ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return scopeLinkLocal
+	}
+	if ip.IsInterfaceLocalMulticast() {
+		return scopeInterfaceLocal
+	}
+	if len(ip) == IPv6len && ip[0] == 0xfe && ip[1]&0xc0 == 0xc0 {
+		return scopeSiteLocal
+	}
+	return scopeGlobal
+}
+
+// labelOf and precedenceOf implement the default policy table from RFC 6724
+// section 2.1. The table is consulted by longest matching prefix.
+type policyEntry struct {
+	prefix     IP
+	bits       int
+	precedence uint8
+	label      uint8
+}
+
+var rfc6724Policy = []policyEntry{
+	{ParseIP("::1"), 128, 50, 0},
+	{ParseIP("::"), 0, 40, 1},
+	{ParseIP("::ffff:0:0"), 96, 35, 4},
+	{ParseIP("2002::"), 16, 30, 2},
+	{ParseIP("2001::"), 32, 5, 5},
+	{ParseIP("fc00::"), 7, 3, 13},
+	{ParseIP("fec0::"), 10, 1, 11},
+	{ParseIP("3ffe::"), 16, 1, 12},
+}
+
+func classify(ip IP) policyEntry {
+	best := policyEntry{precedence: 1, label: 1}
+	bestBits := -1
+	for _, e := range rfc6724Policy {
+		if commonPrefixLen(e.prefix, ip) >= e.bits && e.bits > bestBits {
+			best, bestBits = e, e.bits
+		}
+	}
+	return best
+}
+
+func labelOf(ip IP) uint8      { return classify(ip).label }
+func precedenceOf(ip IP) uint8 { return classify(ip).precedence }
+
+// commonPrefixLen returns the number of leading bits that a and b share,
+// comparing them as 128-bit (IPv4-mapped, where necessary) addresses.
+func commonPrefixLen(a, b IP) int {
+	a16, b16 := a.To16(), b.To16()
+	if a16 == nil || b16 == nil {
+		return 0
+	}
+	n := 0
+	for i := 0; i < len(a16); i++ {
+		x := a16[i] ^ b16[i]
+		if x == 0 {
+			n += 8
+			continue
+		}
+		for x&0x80 == 0 {
+			n++
+			x <<= 1
+		}
+		break
+	}
+	return n
+}
+
+// srcAddrs returns, for each entry in dsts, the local address the kernel
+// would choose to reach it, or nil if that can't be determined. It never
+// sends a packet: opening a UDP socket and calling connect is enough to
+// make the kernel pick a route and bind a local address.
+func srcAddrs(dsts []IPAddr) []IP {
+	srcs := make([]IP, len(dsts))
+	for i, dst := range dsts {
+		c, err := DialUDP("udp", nil, &UDPAddr{IP: dst.IP, Port: 53, Zone: dst.Zone})
+		if err != nil {
+			continue
+		}
+		if a, ok := c.LocalAddr().(*UDPAddr); ok {
+			srcs[i] = a.IP
+		}
+		c.Close()
+	}
+	return srcs
+}
@@ -0,0 +1,122 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import "testing"
+
+var commonPrefixLenTests = []struct {
+	a, b IP
+	want int
+}{
+	{ParseIP("2001:db8::1"), ParseIP("2001:db8::2"), 126},
+	{ParseIP("2001:db8::1"), ParseIP("2001:db9::1"), 31},
+	{ParseIP("::1"), ParseIP("::1"), 128},
+	{IPv4(127, 0, 0, 1), IPv4(127, 0, 0, 2), 126},
+	{IPv4(127, 0, 0, 1), IPv4(10, 0, 0, 1), 97},
+}
+
+func TestCommonPrefixLen(t *testing.T) {
+	for i, tt := range commonPrefixLenTests {
+		if got := commonPrefixLen(tt.a, tt.b); got != tt.want {
+			t.Errorf("#%v: commonPrefixLen(%v, %v) = %v; want %v", i, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+var scopeOfTests = []struct {
+	ip   IP
+	want ipv6Scope
+}{
+	{IPv4(127, 0, 0, 1), scopeInterfaceLocal},
+	{IPv4(192, 168, 0, 1), scopeGlobal},
+	{IPv6loopback, scopeInterfaceLocal},
+	{ParseIP("fe80::1"), scopeLinkLocal},
+	{ParseIP("2001:db8::1"), scopeGlobal},
+}
+
+func TestScopeOf(t *testing.T) {
+	for i, tt := range scopeOfTests {
+		if got := scopeOf(tt.ip); got != tt.want {
+			t.Errorf("#%v: scopeOf(%v) = %v; want %v", i, tt.ip, got, tt.want)
+		}
+	}
+}
+
+// sortByRFC6724withSrcsTests exercises byRFC6724.Less end-to-end, with
+// synthetic source addresses standing in for srcAddrs' kernel routing
+// lookups, following the RFC 6724 section 10.2 worked examples.
+var sortByRFC6724withSrcsTests = []struct {
+	name string
+	ips  []IPAddr
+	srcs []IP
+	want []IPAddr
+}{
+	{
+		// Rule 1: a destination with no usable source address sorts last.
+		name: "avoid unusable",
+		ips: []IPAddr{
+			{IP: ParseIP("2001:db8::1")},
+			{IP: IPv4(192, 0, 2, 1)},
+		},
+		srcs: []IP{nil, IPv4(192, 0, 2, 100)},
+		want: []IPAddr{
+			{IP: IPv4(192, 0, 2, 1)},
+			{IP: ParseIP("2001:db8::1")},
+		},
+	},
+	{
+		// Rule 2: prefer the destination whose scope matches its source.
+		name: "matching scope",
+		ips: []IPAddr{
+			{IP: ParseIP("fe80::1")},     // link-local dest, global src: mismatch
+			{IP: ParseIP("2001:db8::1")}, // global dest, global src: match
+		},
+		srcs: []IP{ParseIP("2001:db8::100"), ParseIP("2001:db8::100")},
+		want: []IPAddr{
+			{IP: ParseIP("2001:db8::1")},
+			{IP: ParseIP("fe80::1")},
+		},
+	},
+	{
+		// Rule 6: prefer higher precedence (loopback over global).
+		name: "precedence",
+		ips: []IPAddr{
+			{IP: ParseIP("2001:db8::1")},
+			{IP: IPv6loopback},
+		},
+		srcs: []IP{ParseIP("2001:db8::100"), IPv6loopback},
+		want: []IPAddr{
+			{IP: IPv6loopback},
+			{IP: ParseIP("2001:db8::1")},
+		},
+	},
+	{
+		// Rule 9: prefer the longer matching prefix against the source.
+		name: "longest matching prefix",
+		ips: []IPAddr{
+			{IP: ParseIP("2001:db8:1::1")},
+			{IP: ParseIP("2001:db8:2::1")},
+		},
+		srcs: []IP{ParseIP("2001:db8:2::100"), ParseIP("2001:db8:2::100")},
+		want: []IPAddr{
+			{IP: ParseIP("2001:db8:2::1")},
+			{IP: ParseIP("2001:db8:1::1")},
+		},
+	},
+}
+
+func TestSortByRFC6724withSrcs(t *testing.T) {
+	for _, tt := range sortByRFC6724withSrcsTests {
+		ips := append([]IPAddr(nil), tt.ips...)
+		srcs := append([]IP(nil), tt.srcs...)
+		sortByRFC6724withSrcs(ips, srcs)
+		for i, want := range tt.want {
+			if !ips[i].IP.Equal(want.IP) {
+				t.Errorf("%s: sortByRFC6724withSrcs() = %v; want %v", tt.name, ips, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,323 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"time"
+)
+
+// defaultConnectionAttemptDelay is the RFC 8305 "Connection Attempt Delay":
+// the time to wait after starting one connection attempt before starting
+// the next one, when multiple addresses are available.
+const defaultConnectionAttemptDelay = 250 * time.Millisecond
+
+// A Dialer contains options for connecting to an address.
+//
+// The zero value for each field is equivalent to dialing without that
+// option. Dialing with the zero value of Dialer is therefore equivalent
+// to just calling the Dial function.
+type Dialer struct {
+	// Timeout is the maximum amount of time a dial will wait for a
+	// connect to complete. If Deadline is also set, it may fail earlier.
+	//
+	// The default is no timeout.
+	//
+	// When using TCP, and dialing a host name with multiple IP
+	// addresses, the timeout may be divided between them.
+	Timeout time.Duration
+
+	// Deadline is the absolute point in time after which dials
+	// will fail. If Timeout is also set, it may fail earlier.
+	// Zero means no deadline, or dependent on the operating system
+	// as with the Timeout option.
+	Deadline time.Time
+
+	// LocalAddr is the local address to use when dialing an
+	// address. The address must be of a compatible type for the
+	// network being dialed.
+	// If nil, a local address is automatically chosen.
+	LocalAddr Addr
+
+	// ConnectionAttemptDelay is the RFC 8305 "Connection Attempt Delay":
+	// the time to wait before starting the next connection attempt when
+	// resolveInternetAddrs returns more than one address. Dialing the
+	// addresses of one winning family doesn't wait for addresses of the
+	// other family; as soon as any attempt succeeds, every other attempt
+	// in flight is cancelled.
+	//
+	// If zero, a default delay of 250ms is used, per RFC 8305 section 8.
+	ConnectionAttemptDelay time.Duration
+
+	// Resolver is used to resolve host names to IP addresses. If nil,
+	// DefaultResolver is used.
+	Resolver Resolver
+
+	// LookupTimeout bounds how long name resolution may take, counted
+	// from the start of the dial; resolution still ends no later than the
+	// overall dial deadline (Timeout, Deadline and the context's). If
+	// zero, resolution shares the overall dial deadline, the same as the
+	// connect step. The connect step keeps the overall deadline either
+	// way, so time spent resolving comes out of the time left for it.
+	LookupTimeout time.Duration
+}
+
+// Dial connects to the address on the named network.
+//
+// Known networks are "tcp", "tcp4" (IPv4-only), "tcp6" (IPv6-only),
+// "udp", "udp4" (IPv4-only), "udp6" (IPv6-only), "ip", "ip4"
+// (IPv4-only), "ip6" (IPv6-only), "unix", "unixgram" and
+// "unixpacket".
+//
+// For TCP and UDP networks, addresses have the form host:port.
+// If host is a literal IPv6 address it must be enclosed in square
+// brackets as in "[::1]:80" or "[ipv6-host%zone]:80".
+func Dial(network, address string) (Conn, error) {
+	var d Dialer
+	return d.Dial(network, address)
+}
+
+// DialTimeout acts like Dial but takes a timeout.
+// The timeout includes name resolution, if required.
+func DialTimeout(network, address string, timeout time.Duration) (Conn, error) {
+	d := Dialer{Timeout: timeout}
+	return d.Dial(network, address)
+}
+
+func (d *Dialer) deadline() time.Time {
+	if d.Timeout == 0 {
+		return d.Deadline
+	}
+	timeoutDeadline := time.Now().Add(d.Timeout)
+	if d.Deadline.IsZero() || timeoutDeadline.Before(d.Deadline) {
+		return timeoutDeadline
+	}
+	return d.Deadline
+}
+
+// lookupDeadline returns the deadline that bounds name resolution. When
+// LookupTimeout is set, it's the earlier of now+LookupTimeout and overall
+// (the connect deadline); otherwise resolution simply shares overall.
+func (d *Dialer) lookupDeadline(overall time.Time) time.Time {
+	if d.LookupTimeout <= 0 {
+		return overall
+	}
+	lookupDeadline := time.Now().Add(d.LookupTimeout)
+	if overall.IsZero() || lookupDeadline.Before(overall) {
+		return lookupDeadline
+	}
+	return overall
+}
+
+func (d *Dialer) connectionAttemptDelay() time.Duration {
+	if d.ConnectionAttemptDelay > 0 {
+		return d.ConnectionAttemptDelay
+	}
+	return defaultConnectionAttemptDelay
+}
+
+// Dial connects to the address on the named network.
+//
+// See func Dial for a description of the network and address
+// parameters.
+func (d *Dialer) Dial(network, address string) (Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to the address on the named network using the
+// provided context.
+//
+// The provided Context must be non-nil. If the context expires before the
+// connection is complete, an error is returned. Once successfully
+// connected, any expiration of the context will not affect the connection.
+//
+// When using TCP, and the host in the address parameter resolves to
+// multiple IP addresses, DialContext will try each IP address in turn,
+// per RFC 8305 ("Happy Eyeballs v2"), until one succeeds or the context
+// expires.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (Conn, error) {
+	deadline := d.deadline()
+	if ctxDeadline, ok := ctx.Deadline(); ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+		deadline = ctxDeadline
+	}
+	addrs, err := resolveInternetAddrs(ctx, resolverFor(d), network, address, d.lookupDeadline(deadline))
+	if err != nil {
+		return nil, &OpError{Op: "dial", Net: network, Err: err}
+	}
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	ordered := addrs.interleaved()
+	if len(ordered) == 1 {
+		return dialSingle(ctx, network, d.LocalAddr, ordered[0])
+	}
+	return dialMulti(ctx, network, d.LocalAddr, ordered, d.connectionAttemptDelay())
+}
+
+// DialContext connects to the address on the named network using the
+// provided context. See func Dial for a description of the network and
+// address parameters, and (*Dialer).DialContext for the ctx semantics.
+func DialContext(ctx context.Context, network, address string) (Conn, error) {
+	var d Dialer
+	return d.DialContext(ctx, network, address)
+}
+
+// dialSingle dials a single, already-resolved address.
+func dialSingle(ctx context.Context, network string, laddr, raddr Addr) (Conn, error) {
+	return dialEndpoint(ctx, network, laddr, raddr)
+}
+
+// dialResult pairs a dial outcome with the cancel func for the context its
+// attempt ran under, so the scheduler can tear down a loser once a winner
+// is found.
+type dialResult struct {
+	conn   Conn
+	err    error
+	cancel context.CancelFunc
+}
+
+// dialMulti implements the RFC 8305 Happy Eyeballs v2 connection scheduler:
+// a single goroutine drives a timer, starting one attempt on each address in
+// ordered (already interleaved by family) every attemptDelay, and returns as
+// soon as any attempt succeeds. Every other attempt's context is cancelled
+// at that point, so dialEndpoint can abandon an in-flight connect and close
+// a winner that shows up late; any attempt that fails before the timer
+// fires causes the next address to be started immediately.
+func dialMulti(ctx context.Context, network string, laddr Addr, ordered []Addr, attemptDelay time.Duration) (Conn, error) {
+	results := make(chan dialResult, len(ordered))
+	var cancels []context.CancelFunc
+	cancelAll := func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+	defer cancelAll()
+
+	inFlight := 0
+	next := 0
+	var timer *time.Timer
+	var timerC <-chan time.Time
+	var firstErr error
+
+	start := func() {
+		raddr := ordered[next]
+		next++
+		inFlight++
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		go func() {
+			c, err := dialEndpoint(attemptCtx, network, laddr, raddr)
+			results <- dialResult{conn: c, err: err, cancel: cancel}
+		}()
+	}
+
+	armTimer := func() {
+		if next >= len(ordered) {
+			timerC = nil
+			return
+		}
+		if timer == nil {
+			timer = time.NewTimer(attemptDelay)
+		} else {
+			// Stop and drain before Reset: if the results case won a race
+			// against an already-fired timer this same tick, a stale fire
+			// would otherwise sit in the channel and be read as if the
+			// freshly-armed timer had already expired.
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(attemptDelay)
+		}
+		timerC = timer.C
+	}
+
+	start()
+	armTimer()
+	for inFlight > 0 {
+		select {
+		case res := <-results:
+			inFlight--
+			if res.err == nil {
+				if timer != nil {
+					timer.Stop()
+				}
+				// Cancelling every other attempt's context (including this
+				// winner's own, a harmless no-op) tells any connect that's
+				// still in flight to abandon itself, and closes the
+				// connection of one that sneaks in afterward anyway.
+				cancelAll()
+				return res.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			if next < len(ordered) && timerC != nil {
+				// Don't wait for the delay if an earlier attempt just
+				// failed; move on to the next address right away.
+				start()
+				armTimer()
+			}
+		case <-timerC:
+			start()
+			armTimer()
+		}
+	}
+	if firstErr == nil {
+		firstErr = errNoSuitableAddress
+	}
+	return nil, &OpError{Op: "dial", Net: network, Err: firstErr}
+}
+
+// dialEndpoint opens a connection to a single, already-resolved address,
+// the equivalent of upstream Go's (*sysDialer).dialSingle: it dispatches on
+// raddr's concrete type to the matching DialTCP/DialUDP/DialIP entry point.
+// Those don't take a context themselves, so cancellation is layered on top
+// here -- if ctx is done before the dial finishes, dialEndpoint returns
+// immediately with ctx.Err(), and closes the connection itself if one
+// eventually completes anyway, so a cancelled loser never leaks a socket.
+func dialEndpoint(ctx context.Context, network string, laddr, raddr Addr) (Conn, error) {
+	type result struct {
+		conn Conn
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		c, err := dialAddr(network, laddr, raddr)
+		ch <- result{c, err}
+	}()
+	select {
+	case r := <-ch:
+		return r.conn, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-ch; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, &OpError{Op: "dial", Net: network, Addr: raddr, Err: ctx.Err()}
+	}
+}
+
+// dialAddr performs the actual, uncancellable connect for one address.
+func dialAddr(network string, laddr, raddr Addr) (Conn, error) {
+	switch ra := raddr.(type) {
+	case *TCPAddr:
+		la, _ := laddr.(*TCPAddr)
+		return DialTCP(network, la, ra)
+	case *UDPAddr:
+		la, _ := laddr.(*UDPAddr)
+		return DialUDP(network, la, ra)
+	case *IPAddr:
+		la, _ := laddr.(*IPAddr)
+		return DialIP(network, la, ra)
+	default:
+		return nil, &AddrError{Err: "unsupported address type", Addr: raddr.String()}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// listenerAddr starts a TCP listener on loopback that accepts and
+// immediately closes every connection, and returns its address along
+// with a func to shut it down.
+func listenerAddr(t *testing.T) (Addr, func()) {
+	t.Helper()
+	l, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				close(done)
+				return
+			}
+			c.Close()
+		}
+	}()
+	return l.Addr(), func() {
+		l.Close()
+		<-done
+	}
+}
+
+// refusingAddr returns the address of a TCP listener that has already
+// been closed, so connecting to it fails immediately with "connection
+// refused" instead of timing out.
+func refusingAddr(t *testing.T) Addr {
+	t.Helper()
+	l, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	addr := l.Addr()
+	l.Close()
+	return addr
+}
+
+func TestDialMultiFailsOverBeforeTimer(t *testing.T) {
+	good, stop := listenerAddr(t)
+	defer stop()
+	bad := refusingAddr(t)
+
+	const attemptDelay = 10 * time.Second // would make the test hang if not skipped
+	start := time.Now()
+	c, err := dialMulti(context.Background(), "tcp", nil, []Addr{bad, good}, attemptDelay)
+	if err != nil {
+		t.Fatalf("dialMulti: %v", err)
+	}
+	defer c.Close()
+	if elapsed := time.Since(start); elapsed > attemptDelay/2 {
+		t.Fatalf("dialMulti took %v to fail over from a refused address; want well under the %v attempt delay", elapsed, attemptDelay)
+	}
+}
+
+func TestDialMultiReturnsOnFirstWin(t *testing.T) {
+	win, stopWin := listenerAddr(t)
+	defer stopWin()
+	lose, stopLose := listenerAddr(t)
+	defer stopLose()
+
+	// A zero attempt delay starts every address as fast as dialMulti's loop
+	// can run, so both attempts race rather than win trivially by being the
+	// only one tried.
+	start := time.Now()
+	c, err := dialMulti(context.Background(), "tcp", nil, []Addr{win, lose}, 0)
+	if err != nil {
+		t.Fatalf("dialMulti: %v", err)
+	}
+	defer c.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("dialMulti took %v to return after its first attempt succeeded; want it to return immediately instead of waiting on the loser", elapsed)
+	}
+}
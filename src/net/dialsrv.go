@@ -0,0 +1,174 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// byPriorityWeight sorts SRV records by priority and, within a priority,
+// randomizes them by weight, following the selection procedure from
+// RFC 2782 section "Usage rules": a weighted-random (reservoir sampling)
+// pick among records of equal priority, with weight-0 records tried first
+// among ties, in random order.
+type byPriorityWeight []*SRV
+
+func (s byPriorityWeight) Len() int      { return len(s) }
+func (s byPriorityWeight) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byPriorityWeight) Less(i, j int) bool {
+	return s[i].Priority < s[j].Priority ||
+		(s[i].Priority == s[j].Priority && s[i].Weight < s[j].Weight)
+}
+
+// shuffleByWeight randomizes the order of a contiguous run of SRV records
+// that all share one priority. The ascending-weight ordering Less
+// established puts the run's weight-0 records at its front; they stay
+// there, shuffled so that every order among them is equally likely. The
+// weighted records after them are ordered with the running-sum algorithm
+// from RFC 2782: repeatedly pick a random point in [0, sum of remaining
+// weights] and promote whichever record's cumulative weight covers it.
+func (addrs byPriorityWeight) shuffleByWeight() {
+	zeros := 0
+	for zeros < len(addrs) && addrs[zeros].Weight == 0 {
+		zeros++
+	}
+	for i := zeros - 1; i > 0; i-- {
+		j := rand.Intn(i + 1)
+		addrs[i], addrs[j] = addrs[j], addrs[i]
+	}
+	addrs = addrs[zeros:]
+
+	sum := 0
+	for _, addr := range addrs {
+		sum += int(addr.Weight)
+	}
+	for sum > 0 && len(addrs) > 1 {
+		s := 0
+		n := rand.Intn(sum + 1)
+		for i := range addrs {
+			s += int(addrs[i].Weight)
+			if s >= n {
+				addrs[0], addrs[i] = addrs[i], addrs[0]
+				break
+			}
+		}
+		sum -= int(addrs[0].Weight)
+		addrs = addrs[1:]
+	}
+}
+
+// sort orders addrs ascending by Priority, then applies shuffleByWeight
+// within each priority band.
+func (addrs byPriorityWeight) sort() {
+	sort.Stable(addrs)
+	i := 0
+	for j := 1; j < len(addrs); j++ {
+		if addrs[i].Priority != addrs[j].Priority {
+			addrs[i:j].shuffleByWeight()
+			i = j
+		}
+	}
+	addrs[i:].shuffleByWeight()
+}
+
+// DialSRV looks up the SRV records for _service._proto.name, orders the
+// targets per RFC 2782 (see byPriorityWeight), and dials each target's
+// resolved addresses in that order -- using the same RFC 8305 Happy
+// Eyeballs v2 scheduler as Dial -- falling over to the next SRV target if
+// one fails to connect. It's meant for SRV-driven protocols such as XMPP,
+// SIP, and Kubernetes headless Services, where both the hosts serving a
+// name and the port to use are published in DNS rather than fixed by
+// convention.
+//
+// proto selects both the RR query (_service._proto.name) and the network
+// used to dial each resolved address: "udp" dials "udp", anything else
+// dials "tcp".
+func (d *Dialer) DialSRV(ctx context.Context, service, proto, name string) (Conn, error) {
+	resolver := resolverFor(d)
+	deadline := d.deadline()
+	if ctxDeadline, ok := ctx.Deadline(); ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+		deadline = ctxDeadline
+	}
+
+	lookupDeadline := d.lookupDeadline(deadline)
+	lookupCtx, cancel := withLookupDeadline(ctx, lookupDeadline)
+	_, srvs, err := resolver.LookupSRV(lookupCtx, service, proto, name)
+	cancel()
+	if err != nil {
+		return nil, &OpError{Op: "dial", Net: proto, Err: err}
+	}
+	if len(srvs) == 0 {
+		return nil, &OpError{Op: "dial", Net: proto, Err: errNoSuitableAddress}
+	}
+	byPriorityWeight(srvs).sort()
+
+	network := "tcp"
+	if proto == "udp" {
+		network = "udp"
+	}
+
+	dialCtx := ctx
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	var firstErr error
+	for _, srv := range srvs {
+		addrs, err := resolveSRVTarget(ctx, resolver, network, srv, lookupDeadline)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		ordered := addrs.interleaved()
+		var conn Conn
+		if len(ordered) == 1 {
+			conn, err = dialSingle(dialCtx, network, d.LocalAddr, ordered[0])
+		} else {
+			conn, err = dialMulti(dialCtx, network, d.LocalAddr, ordered, d.connectionAttemptDelay())
+		}
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, &OpError{Op: "dial", Net: network, Err: firstErr}
+}
+
+// DialSRV is the package-level form of (*Dialer).DialSRV, using
+// DefaultResolver and no explicit timeout or deadline.
+func DialSRV(ctx context.Context, service, proto, name string) (Conn, error) {
+	var d Dialer
+	return d.DialSRV(ctx, service, proto, name)
+}
+
+// resolveSRVTarget resolves one SRV target's A/AAAA records and tags them
+// with the SRV-supplied port, the same way resolveInternetAddrs tags a
+// literal host:port.
+func resolveSRVTarget(ctx context.Context, resolver Resolver, network string, srv *SRV, lookupDeadline time.Time) (addrList, error) {
+	lookupCtx, cancel := withLookupDeadline(ctx, lookupDeadline)
+	defer cancel()
+	ips, err := resolver.LookupIPAddr(lookupCtx, srv.Target)
+	if err != nil {
+		return addrList{}, err
+	}
+	sortByRFC6724(ips)
+	port := int(srv.Port)
+	inetaddr := func(ip IPAddr) Addr {
+		if network == "udp" {
+			return &UDPAddr{IP: ip.IP, Port: port, Zone: ip.Zone}
+		}
+		return &TCPAddr{IP: ip.IP, Port: port, Zone: ip.Zone}
+	}
+	return filterAndTagAddrs(nil, ips, inetaddr)
+}
@@ -0,0 +1,138 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"testing"
+)
+
+func TestByPriorityWeightSortsByPriority(t *testing.T) {
+	srvs := []*SRV{
+		{Target: "c", Priority: 2, Weight: 0},
+		{Target: "a", Priority: 0, Weight: 0},
+		{Target: "b", Priority: 1, Weight: 0},
+	}
+	byPriorityWeight(srvs).sort()
+	want := []string{"a", "b", "c"}
+	for i, w := range want {
+		if srvs[i].Target != w {
+			t.Fatalf("sort() order = %v; want %v", targets(srvs), want)
+		}
+	}
+}
+
+func TestByPriorityWeightKeepsPriorityBands(t *testing.T) {
+	srvs := []*SRV{
+		{Target: "a", Priority: 0, Weight: 10},
+		{Target: "b", Priority: 0, Weight: 20},
+		{Target: "c", Priority: 1, Weight: 5},
+		{Target: "d", Priority: 1, Weight: 0},
+	}
+	for i := 0; i < 20; i++ {
+		byPriorityWeight(srvs).sort()
+		for _, s := range srvs[:2] {
+			if s.Priority != 0 {
+				t.Fatalf("priority-0 record sorted out of its band: %v", targets(srvs))
+			}
+		}
+		for _, s := range srvs[2:] {
+			if s.Priority != 1 {
+				t.Fatalf("priority-1 record sorted out of its band: %v", targets(srvs))
+			}
+		}
+	}
+}
+
+func TestByPriorityWeightPlacesZeroWeightFirst(t *testing.T) {
+	// A weight-0 record appearing after nonzero-weight records in the raw
+	// answer must still end up tried first, per RFC 2782's "place weight-0
+	// RRs at the beginning of the list" rule.
+	for i := 0; i < 1000; i++ {
+		srvs := []*SRV{
+			{Target: "a", Priority: 0, Weight: 10},
+			{Target: "b", Priority: 0, Weight: 20},
+			{Target: "zero", Priority: 0, Weight: 0},
+		}
+		byPriorityWeight(srvs).sort()
+		if srvs[0].Target != "zero" {
+			t.Fatalf("sort() order = %v; want zero-weight record first", targets(srvs))
+		}
+	}
+}
+
+func TestByPriorityWeightShufflesRemainderAfterZeroWeight(t *testing.T) {
+	// Drawing a weight-0 record out of a band must not stop the rest of
+	// the band from being shuffled: run the sort enough times that, if the
+	// remaining weighted records were left untouched, we'd see it.
+	sawReordered := false
+	for i := 0; i < 2000; i++ {
+		srvs := []*SRV{
+			{Target: "zero", Priority: 0, Weight: 0},
+			{Target: "a", Priority: 0, Weight: 10},
+			{Target: "b", Priority: 0, Weight: 10},
+		}
+		byPriorityWeight(srvs).sort()
+		if targets(srvs)[1] == "b" {
+			sawReordered = true
+			break
+		}
+	}
+	if !sawReordered {
+		t.Fatal("sort() never reordered the weighted remainder after a zero-weight draw")
+	}
+}
+
+// stubSRVResolver is a Resolver that hands back a fixed SRV answer and
+// resolves every target name to loopback, so DialSRV's failover logic can
+// be driven against real local listeners without a DNS server.
+type stubSRVResolver struct {
+	srvs []*SRV
+}
+
+func (r *stubSRVResolver) LookupIPAddr(ctx context.Context, host string) ([]IPAddr, error) {
+	return []IPAddr{{IP: IPv4(127, 0, 0, 1)}}, nil
+}
+
+func (r *stubSRVResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*SRV, error) {
+	return name, r.srvs, nil
+}
+
+func (r *stubSRVResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return nil, nil
+}
+
+func TestDialSRVFailsOverToNextTarget(t *testing.T) {
+	good, stop := listenerAddr(t)
+	defer stop()
+	bad := refusingAddr(t)
+
+	goodPort := good.(*TCPAddr).Port
+	badPort := bad.(*TCPAddr).Port
+
+	d := &Dialer{Resolver: &stubSRVResolver{srvs: []*SRV{
+		// Lower Priority sorts first, so DialSRV must try (and fail over
+		// from) bad.example before ever reaching good.example.
+		{Target: "bad.example.", Port: uint16(badPort), Priority: 0, Weight: 0},
+		{Target: "good.example.", Port: uint16(goodPort), Priority: 1, Weight: 0},
+	}}}
+
+	c, err := d.DialSRV(context.Background(), "xmpp-client", "tcp", "example.com")
+	if err != nil {
+		t.Fatalf("DialSRV: %v", err)
+	}
+	defer c.Close()
+	if got := c.RemoteAddr().(*TCPAddr).Port; got != goodPort {
+		t.Fatalf("DialSRV connected to port %v; want it to have failed over to the good target's port %v", got, goodPort)
+	}
+}
+
+func targets(srvs []*SRV) []string {
+	out := make([]string, len(srvs))
+	for i, s := range srvs {
+		out[i] = s.Target
+	}
+	return out
+}
@@ -0,0 +1,42 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// DNS message packing and unpacking for the pure Go stub resolver
+// (goResolver). The actual wire-format code lives in net/internal/dnsmsg,
+// shared with net/dot's DoT/DoH resolver; this file just aliases it under
+// the lowercase names the rest of the package already uses.
+
+package net
+
+import "net/internal/dnsmsg"
+
+const (
+	dnsTypeA     = dnsmsg.TypeA
+	dnsTypeCNAME = dnsmsg.TypeCNAME
+	dnsTypeAAAA  = dnsmsg.TypeAAAA
+	dnsTypeSRV   = dnsmsg.TypeSRV
+	dnsTypeTXT   = dnsmsg.TypeTXT
+	dnsClassINET = dnsmsg.ClassINET
+)
+
+var errDNSMessage = dnsmsg.ErrMessage
+
+type dnsRR = dnsmsg.RR
+type dnsMsg = dnsmsg.Msg
+
+func packQuery(id uint16, name string, qtype uint16) []byte {
+	return dnsmsg.PackQuery(id, name, qtype)
+}
+
+func packName(name string) []byte {
+	return dnsmsg.PackName(name)
+}
+
+func unpackMsg(msg []byte) (*dnsMsg, error) {
+	return dnsmsg.UnpackMsg(msg)
+}
+
+func unpackName(msg []byte, off int) (string, int, error) {
+	return dnsmsg.UnpackName(msg, off)
+}
@@ -0,0 +1,64 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestPackUnpackName(t *testing.T) {
+	tests := []string{"www.example.com", "example.com", "."}
+	for _, name := range tests {
+		packed := packName(name)
+		got, off, err := unpackName(packed, 0)
+		if err != nil {
+			t.Errorf("unpackName(%q): %v", name, err)
+			continue
+		}
+		if off != len(packed) {
+			t.Errorf("unpackName(%q): consumed %v bytes; want %v", name, off, len(packed))
+		}
+		if got != name {
+			t.Errorf("unpackName(packName(%q)) = %q", name, got)
+		}
+	}
+}
+
+func TestUnpackNameCompression(t *testing.T) {
+	// "example.com" at offset 0, then "www.example.com" as "www" followed
+	// by a compression pointer back to offset 0.
+	msg := packName("example.com")
+	ptrOff := len(msg)
+	msg = append(msg, 3, 'w', 'w', 'w', 0xc0, 0x00)
+
+	name, off, err := unpackName(msg, ptrOff)
+	if err != nil {
+		t.Fatalf("unpackName: %v", err)
+	}
+	if want := "www.example.com"; name != want {
+		t.Errorf("unpackName = %q; want %q", name, want)
+	}
+	if want := ptrOff + 6; off != want {
+		t.Errorf("unpackName consumed up to %v; want %v (must stop after the pointer, not follow it)", off, want)
+	}
+}
+
+func TestPackUnpackMsg(t *testing.T) {
+	query := packQuery(0x1234, "example.com", dnsTypeA)
+	if binary.BigEndian.Uint16(query[0:]) != 0x1234 {
+		t.Fatalf("packQuery: id not encoded correctly")
+	}
+	msg, err := unpackMsg(query)
+	if err != nil {
+		t.Fatalf("unpackMsg(packQuery(...)): %v", err)
+	}
+	if msg.Question != "example.com" {
+		t.Errorf("question = %q; want %q", msg.Question, "example.com")
+	}
+	if msg.Qtype != dnsTypeA {
+		t.Errorf("qtype = %v; want %v", msg.Qtype, dnsTypeA)
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dot implements DNS-over-TLS (RFC 7858) and DNS-over-HTTPS
+// (RFC 8484) resolution for use as a net.Resolver.
+//
+// This package intentionally lives outside net, and imports it, rather
+// than the other way around: a DoT/DoH resolver needs crypto/tls and
+// net/http, and both of those import net, so pulling them into net itself
+// would be an import cycle. Keeping dot as a consumer of net -- the same
+// relationship net/addrsel has, just inverted -- avoids that. The DNS
+// wire-format pack/unpack code it shares with net's pure Go stub resolver
+// lives in net/internal/dnsmsg, where both can import it.
+package dot
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/internal/dnsmsg"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Resolver is a net.Resolver that sends RFC 8484 wire-format DNS queries
+// to a single encrypted upstream, rather than trusting whatever resolver
+// the host network hands out over DHCP. Upstream determines the transport:
+//
+//	tls://host:port   DNS-over-TLS (RFC 7858): one query/response message
+//	                  per TLS connection, length-prefixed exactly like the
+//	                  classic TCP DNS transport.
+//	https://host/path DNS-over-HTTPS (RFC 8484): the query is POSTed as
+//	                  application/dns-message and the answer read back
+//	                  from the response body, over HTTP/2.
+//
+// LookupIPAddr's A and AAAA answers are cached in-memory, keyed by (qtype,
+// name), honoring the TTL of the least long-lived record in the answer;
+// LookupSRV and LookupTXT are not cached. Set a *Resolver as a
+// Dialer.Resolver to use it for that dialer's lookups.
+type Resolver struct {
+	Upstream string
+
+	cache dnsmsg.TTLCache
+}
+
+func (r *Resolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	var out []net.IPAddr
+	var lastErr error
+	for _, qtype := range []uint16{dnsmsg.TypeA, dnsmsg.TypeAAAA} {
+		key := dnsmsg.CacheKey(host, qtype)
+		if v, err, ok := r.cache.Get(key); ok {
+			if err == nil {
+				out = append(out, v.([]net.IPAddr)...)
+			}
+			continue
+		}
+		msg, err := r.exchange(ctx, host, qtype)
+		if err != nil {
+			lastErr = err
+			r.cache.Put(key, nil, err, dnsmsg.NegativeCacheTTL)
+			continue
+		}
+		ips, ttl := ipsFromAnswer(msg)
+		r.cache.Put(key, ips, nil, ttl)
+		out = append(out, ips...)
+	}
+	if len(out) == 0 {
+		if lastErr == nil {
+			lastErr = &net.DNSError{Err: "no such host", Name: host}
+		}
+		return nil, lastErr
+	}
+	return out, nil
+}
+
+func (r *Resolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	target := name
+	if service != "" || proto != "" {
+		target = fmt.Sprintf("_%s._%s.%s", service, proto, name)
+	}
+	msg, err := r.exchange(ctx, target, dnsmsg.TypeSRV)
+	if err != nil {
+		return "", nil, err
+	}
+	var srvs []*net.SRV
+	for _, rr := range msg.Answer {
+		if rr.Type != dnsmsg.TypeSRV || len(rr.Data) < 6 {
+			continue
+		}
+		tgt, _, err := dnsmsg.UnpackName(rr.Data, 6)
+		if err != nil {
+			continue
+		}
+		srvs = append(srvs, &net.SRV{
+			Target:   tgt,
+			Port:     uint16(rr.Data[4])<<8 | uint16(rr.Data[5]),
+			Priority: uint16(rr.Data[0])<<8 | uint16(rr.Data[1]),
+			Weight:   uint16(rr.Data[2])<<8 | uint16(rr.Data[3]),
+		})
+	}
+	return target, srvs, nil
+}
+
+func (r *Resolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	msg, err := r.exchange(ctx, name, dnsmsg.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, rr := range msg.Answer {
+		if rr.Type != dnsmsg.TypeTXT {
+			continue
+		}
+		out = append(out, string(rr.Data))
+	}
+	return out, nil
+}
+
+func ipsFromAnswer(msg *dnsmsg.Msg) ([]net.IPAddr, time.Duration) {
+	var out []net.IPAddr
+	minTTL := time.Duration(1<<63 - 1)
+	for _, rr := range msg.Answer {
+		switch rr.Type {
+		case dnsmsg.TypeA:
+			if len(rr.Data) != 4 {
+				continue
+			}
+			out = append(out, net.IPAddr{IP: net.IPv4(rr.Data[0], rr.Data[1], rr.Data[2], rr.Data[3])})
+		case dnsmsg.TypeAAAA:
+			if len(rr.Data) != 16 {
+				continue
+			}
+			ip := make(net.IP, 16)
+			copy(ip, rr.Data)
+			out = append(out, net.IPAddr{IP: ip})
+		default:
+			continue
+		}
+		if ttl := time.Duration(rr.TTL) * time.Second; ttl < minTTL {
+			minTTL = ttl
+		}
+	}
+	return out, minTTL
+}
+
+func (r *Resolver) exchange(ctx context.Context, name string, qtype uint16) (*dnsmsg.Msg, error) {
+	query := dnsmsg.PackQuery(uint16(rand.Intn(1<<16)), name, qtype)
+	switch {
+	case strings.HasPrefix(r.Upstream, "tls://"):
+		return r.exchangeDoT(ctx, strings.TrimPrefix(r.Upstream, "tls://"), query)
+	case strings.HasPrefix(r.Upstream, "https://"):
+		return r.exchangeDoH(ctx, r.Upstream, query)
+	default:
+		return nil, fmt.Errorf("net/dot: unsupported upstream scheme %q (want tls:// or https://)", r.Upstream)
+	}
+}
+
+func (r *Resolver) exchangeDoT(ctx context.Context, hostport string, query []byte) (*dnsmsg.Msg, error) {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	dialer := &tls.Dialer{Config: &tls.Config{ServerName: host}}
+	conn, err := dialer.DialContext(ctx, "tcp", hostport)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := watchContext(ctx, conn)
+	defer stop()
+	lenPrefix := []byte{byte(len(query) >> 8), byte(len(query))}
+	if _, err := conn.Write(append(lenPrefix, query...)); err != nil {
+		return nil, err
+	}
+	var lbuf [2]byte
+	if _, err := readFull(conn, lbuf[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, int(lbuf[0])<<8|int(lbuf[1]))
+	if _, err := readFull(conn, buf); err != nil {
+		return nil, err
+	}
+	return dnsmsg.UnpackMsg(buf)
+}
+
+func (r *Resolver) exchangeDoH(ctx context.Context, endpoint string, query []byte) (*dnsmsg.Msg, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("net/dot: DoH upstream returned %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return dnsmsg.UnpackMsg(body)
+}
+
+func readFull(c net.Conn, buf []byte) (int, error) {
+	n := 0
+	for n < len(buf) {
+		m, err := c.Read(buf[n:])
+		if err != nil {
+			return n, err
+		}
+		n += m
+	}
+	return n, nil
+}
+
+// watchContext arranges for c to be closed if ctx is done before stop is
+// called, so a blocking Read/Write on c unblocks with an error instead of
+// hanging past the caller's budget -- including when ctx carries
+// cancellation but no deadline, where nothing else would ever wake up
+// the read. Callers must call the returned stop func once the exchange
+// is done, successful or not, to release the watcher goroutine.
+func watchContext(ctx context.Context, c net.Conn) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,258 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dnsmsg implements the minimal DNS wire-format packing and
+// unpacking, and the answer cache, shared by package net's pure Go stub
+// resolver (goResolver) and net/dot's DNS-over-TLS/DNS-over-HTTPS
+// resolver. Constants are defined only for the record types those
+// resolvers need (A, AAAA, CNAME, SRV, TXT). UnpackMsg returns every answer
+// record with its raw RDATA, whatever its type; interpreting it, and
+// skipping unwanted types, is left to the caller.
+//
+// It lives under net/internal, rather than in net or net/dot directly, so
+// that both of those packages -- which can't import each other (net/dot
+// imports net; the reverse would cycle, see net/dot's package doc) -- can
+// import this one without duplicating it.
+package dnsmsg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	TypeA     = 1
+	TypeCNAME = 5
+	TypeAAAA  = 28
+	TypeSRV   = 33
+	TypeTXT   = 16
+	ClassINET = 1
+)
+
+// ErrMessage is returned when a DNS message is truncated or malformed
+// beyond what UnpackMsg or UnpackName can make sense of.
+var ErrMessage = errors.New("dnsmsg: invalid DNS message")
+
+// RR is one answer/authority/additional record from a DNS response.
+type RR struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+	Data  []byte
+}
+
+// Msg is a parsed DNS message.
+type Msg struct {
+	ID        uint16
+	RCode     byte
+	Truncated bool
+	Question  string
+	Qtype     uint16
+	Answer    []RR
+}
+
+// PackQuery builds a DNS query for name/qtype with the given id.
+func PackQuery(id uint16, name string, qtype uint16) []byte {
+	buf := make([]byte, 12)
+	binary.BigEndian.PutUint16(buf[0:], id)
+	binary.BigEndian.PutUint16(buf[2:], 0x0100) // RD (recursion desired)
+	binary.BigEndian.PutUint16(buf[4:], 1)      // QDCOUNT
+	buf = append(buf, PackName(name)...)
+	var qtail [4]byte
+	binary.BigEndian.PutUint16(qtail[0:], qtype)
+	binary.BigEndian.PutUint16(qtail[2:], ClassINET)
+	return append(buf, qtail[:]...)
+}
+
+// PackName encodes name as a sequence of length-prefixed labels.
+func PackName(name string) []byte {
+	var out []byte
+	if name != "" && name != "." {
+		start := 0
+		for i := 0; i <= len(name); i++ {
+			if i == len(name) || name[i] == '.' {
+				if i > start {
+					out = append(out, byte(i-start))
+					out = append(out, name[start:i]...)
+				}
+				start = i + 1
+			}
+		}
+	}
+	return append(out, 0)
+}
+
+// UnpackMsg parses a complete DNS message, following compression pointers
+// when reading names.
+func UnpackMsg(msg []byte) (*Msg, error) {
+	if len(msg) < 12 {
+		return nil, ErrMessage
+	}
+	m := &Msg{
+		ID:        binary.BigEndian.Uint16(msg[0:]),
+		RCode:     msg[3] & 0x0f,
+		Truncated: msg[2]&0x02 != 0,
+	}
+	qdcount := binary.BigEndian.Uint16(msg[4:])
+	ancount := binary.BigEndian.Uint16(msg[6:])
+	off := 12
+	for i := 0; i < int(qdcount); i++ {
+		name, next, err := UnpackName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		off = next
+		if off+4 > len(msg) {
+			return nil, ErrMessage
+		}
+		if i == 0 {
+			m.Question = name
+			m.Qtype = binary.BigEndian.Uint16(msg[off:])
+		}
+		off += 4 // qtype + qclass
+	}
+	for i := 0; i < int(ancount); i++ {
+		rr, next, err := unpackRR(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		off = next
+		m.Answer = append(m.Answer, rr)
+	}
+	return m, nil
+}
+
+func unpackRR(msg []byte, off int) (RR, int, error) {
+	name, off, err := UnpackName(msg, off)
+	if err != nil {
+		return RR{}, 0, err
+	}
+	if off+10 > len(msg) {
+		return RR{}, 0, ErrMessage
+	}
+	rr := RR{
+		Name:  name,
+		Type:  binary.BigEndian.Uint16(msg[off:]),
+		Class: binary.BigEndian.Uint16(msg[off+2:]),
+		TTL:   binary.BigEndian.Uint32(msg[off+4:]),
+	}
+	rdlen := int(binary.BigEndian.Uint16(msg[off+8:]))
+	off += 10
+	if off+rdlen > len(msg) {
+		return RR{}, 0, ErrMessage
+	}
+	rr.Data = msg[off : off+rdlen]
+	return rr, off + rdlen, nil
+}
+
+// UnpackName reads a (possibly compressed) domain name starting at off and
+// returns it along with the offset of the byte following the name in the
+// original message (i.e. not following any pointer it may have jumped
+// through).
+func UnpackName(msg []byte, off int) (string, int, error) {
+	var name []byte
+	end := -1 // offset to return once we've followed the first pointer
+	ptrs := 0
+	for {
+		if off >= len(msg) {
+			return "", 0, ErrMessage
+		}
+		c := int(msg[off])
+		switch {
+		case c == 0:
+			off++
+			if end == -1 {
+				end = off
+			}
+			if len(name) == 0 {
+				return ".", end, nil
+			}
+			return string(name[:len(name)-1]), end, nil
+		case c&0xc0 == 0xc0:
+			if off+1 >= len(msg) {
+				return "", 0, ErrMessage
+			}
+			if end == -1 {
+				end = off + 2
+			}
+			ptrs++
+			if ptrs > 20 {
+				return "", 0, ErrMessage // guard against pointer loops
+			}
+			off = (c&0x3f)<<8 | int(msg[off+1])
+		default:
+			off++
+			if off+c > len(msg) {
+				return "", 0, ErrMessage
+			}
+			name = append(name, msg[off:off+c]...)
+			name = append(name, '.')
+			off += c
+		}
+	}
+}
+
+// NegativeCacheTTL bounds how long a failed per-qtype lookup is cached in
+// a TTLCache. Unlike a positive answer, a failure carries no TTL of its
+// own (neither goResolver nor net/dot's Resolver parses the SOA record's
+// negative-caching field out of the authority section), so a short fixed
+// ceiling is used instead -- long enough to absorb a burst of retries
+// against an upstream that's down, short enough that a transient failure
+// doesn't stick around once it clears.
+const NegativeCacheTTL = 5 * time.Second
+
+// CacheKey builds the TTLCache key for a (host, qtype) lookup.
+func CacheKey(host string, qtype uint16) string {
+	return fmt.Sprintf("%d:%s", qtype, host)
+}
+
+// ttlCacheEntry is one entry in a TTLCache.
+type ttlCacheEntry struct {
+	value   interface{} // nil, with err set, for a cached negative answer
+	err     error
+	expires time.Time
+}
+
+// TTLCache is a small in-memory positive/negative answer cache, keyed by
+// CacheKey, for a resolver that talks to a single configured upstream,
+// where repeated lookups for the same host are common and a network
+// round trip per lookup would be wasteful. It's shared by goResolver
+// (package net) and net/dot's Resolver, which otherwise can't share a
+// cache of net.IPAddr values without an import cycle (see this package's
+// doc comment) -- so the cached value is opaque here; callers type-assert
+// it back to whatever they stored. The zero TTLCache is ready to use.
+type TTLCache struct {
+	mu      sync.Mutex
+	entries map[string]ttlCacheEntry
+}
+
+// Get returns the cached value for key, if any, along with whether it was
+// found and not yet expired.
+func (c *TTLCache) Get(key string) (value interface{}, err error, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return nil, nil, false
+	}
+	return e.value, e.err, true
+}
+
+// Put caches value (or err) under key for ttl. A non-positive ttl is
+// treated as "don't cache this".
+func (c *TTLCache) Put(key string, value interface{}, err error, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]ttlCacheEntry)
+	}
+	c.entries[key] = ttlCacheEntry{value: value, err: err, expires: time.Now().Add(ttl)}
+}
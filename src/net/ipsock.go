@@ -7,7 +7,9 @@
 package net
 
 import (
+	"context"
 	"errors"
+	"net/addrsel"
 	"time"
 )
 
@@ -32,27 +34,37 @@ func init() {
 	supportsIPv6, supportsIPv4map = probeIPv6Stack()
 }
 
-
 type addrWithTags struct {
 	Addr
 	// single should be true on exactly one item within an addrList,
 	// to indicate that this address should be used by APIs that cannot
 	// handle more than one.
-	single   bool
+	single bool
 	// fallback may be true on some (but not all) addresses in an addrList,
 	// which moves them to the fallback thread when doing Happy Eyeballs.
 	fallback bool
 }
 
-type addrList []addrWithTags
+// addrList is the tagged, ordered result of filterAndTagAddrs (or, for a
+// literal address needing no resolution, makeAddrList). cands is the
+// addrsel.Candidates it was built from, kept around so interleaved() can
+// call through to addrsel's own RFC 8305 merge instead of recomputing it;
+// it's nil for a makeAddrList single-address list, which has nothing to
+// interleave.
+type addrList struct {
+	addrs []addrWithTags
+	cands addrsel.Candidates
+}
 
 // makeAddrList constructs an addrList list of exactly one element.
 func makeAddrList(addr Addr) addrList {
 	return addrList{
-		addrWithTags{
-			Addr:     addr,
-			single:   true,
-			fallback: false,
+		addrs: []addrWithTags{
+			{
+				Addr:     addr,
+				single:   true,
+				fallback: false,
+			},
 		},
 	}
 }
@@ -61,7 +73,7 @@ func makeAddrList(addr Addr) addrList {
 func (addrs addrList) getSingle() Addr {
 	var out Addr
 	count := 0
-	for _, addr := range addrs {
+	for _, addr := range addrs.addrs {
 		if addr.single {
 			out = addr.Addr
 			count++
@@ -76,7 +88,7 @@ func (addrs addrList) getSingle() Addr {
 // getAll returns every address in order.
 func (addrs addrList) getAll() []Addr {
 	var out []Addr
-	for _, addr := range addrs {
+	for _, addr := range addrs.addrs {
 		out = append(out, addr.Addr)
 	}
 	return out
@@ -86,7 +98,7 @@ func (addrs addrList) getAll() []Addr {
 // When doing Happy Eyeballs, these belong in the primary thread.
 func (addrs addrList) getPrimaries() []Addr {
 	var out []Addr
-	for _, addr := range addrs {
+	for _, addr := range addrs.addrs {
 		if !addr.fallback {
 			out = append(out, addr.Addr)
 		}
@@ -98,7 +110,7 @@ func (addrs addrList) getPrimaries() []Addr {
 // When doing Happy Eyeballs, these belong in the delayed thread.
 func (addrs addrList) getFallbacks() []Addr {
 	var out []Addr
-	for _, addr := range addrs {
+	for _, addr := range addrs.addrs {
 		if addr.fallback {
 			out = append(out, addr.Addr)
 		}
@@ -106,53 +118,78 @@ func (addrs addrList) getFallbacks() []Addr {
 	return out
 }
 
+// interleaved returns every address in addrs, reordered so that the two
+// address families alternate, as described in RFC 8305 section 4
+// ("Staggering Connection Attempts"). It's a thin wrapper around
+// addrsel.Candidates.Interleaved(), so net's own dial path gets exactly the
+// ordering guarantees a third-party dialer built on net/addrsel would. A
+// makeAddrList list has no backing Candidates and needs no interleaving
+// (it's a single address), so it's returned via getAll() unchanged.
+func (addrs addrList) interleaved() []Addr {
+	if len(addrs.cands) == 0 {
+		return addrs.getAll()
+	}
+	out := make([]Addr, 0, len(addrs.cands))
+	for _, a := range addrs.cands.Interleaved() {
+		out = append(out, a.(Addr))
+	}
+	return out
+}
 
 var errNoSuitableAddress = errors.New("no suitable address found")
 
 // filterAndTagAddrs applies a filter to a list of IP addresses, and
 // tags them for use by a Happy Eyeballs algorithm.  Known filters are
 // nil, ipv4only, and ipv6only.  It returns all addresses when the
-// filter is nil.  When error is nil, the resulting getSingle(),
+// filter is nil.  ips is expected to already be ordered by preference,
+// typically by sortByRFC6724; the first surviving address becomes the
+// getSingle() address and the other address family is tagged as the
+// getFallbacks() family, but callers that want the full Happy Eyeballs v2
+// behavior should use interleaved() instead of getPrimaries()/
+// getFallbacks(). When error is nil, the resulting getSingle(),
 // getPrimaries(), and getAll() will return at least one address.
+//
+// The actual bookkeeping lives in net/addrsel, which this package uses
+// like any other consumer, so that third-party dialers built on top of
+// net/addrsel get identical ordering to net.Dial.
 func filterAndTagAddrs(filter func(IPAddr) bool, ips []IPAddr, inetaddr func(IPAddr) Addr) (addrList, error) {
-	var (
-		addrs     addrList
-		v4Addrs   []int
-		v6Addrs   []int
-		fallbacks *[]int
-	)
-	for _, ip := range ips {
-		if filter != nil && !filter(ip) {
-			continue
+	selIPs := make([]addrsel.IPAddr, len(ips))
+	for i, ip := range ips {
+		selIPs[i] = addrsel.IPAddr{IP: []byte(ip.IP), Zone: ip.Zone}
+	}
+	var selFilter func(addrsel.IPAddr) bool
+	if filter != nil {
+		selFilter = func(a addrsel.IPAddr) bool {
+			return filter(IPAddr{IP: IP(a.IP), Zone: a.Zone})
 		}
-		if ipv4only(ip) {
-			if fallbacks == nil {
-				fallbacks = &v6Addrs
-			}
-			v4Addrs = append(v4Addrs, len(addrs))
-			addrs = append(addrs, addrWithTags{Addr: inetaddr(ip)})
-		} else if ipv6only(ip) {
-			if fallbacks == nil {
-				fallbacks = &v4Addrs
-			}
-			v6Addrs = append(v6Addrs, len(addrs))
-			addrs = append(addrs, addrWithTags{Addr: inetaddr(ip)})
+	}
+	mk := func(a addrsel.IPAddr) addrsel.Addr {
+		return inetaddr(IPAddr{IP: IP(a.IP), Zone: a.Zone})
+	}
+	cands, err := addrsel.Filter(selFilter, selIPs, mk)
+	if err != nil {
+		if err == addrsel.ErrNoSuitableAddress {
+			return addrList{}, errNoSuitableAddress
 		}
+		return addrList{}, err
 	}
-	// Tag the one address that getSingle() should return,
-	// while preferring IPv4 for legacy compatibility.
-	if len(v4Addrs) > 0 {
-		addrs[v4Addrs[0]].single = true
-	} else if len(v6Addrs) > 0 {
-		addrs[v6Addrs[0]].single = true
-	} else {
-		return nil, errNoSuitableAddress
+
+	var single Addr = cands.Single()
+	isFallback := make(map[Addr]bool, len(cands.Fallbacks()))
+	for _, a := range cands.Fallbacks() {
+		var addr Addr = a
+		isFallback[addr] = true
 	}
-	// Tag the fallback addresses.
-	for _, i := range *fallbacks {
-		addrs[i].fallback = true
+	tagged := make([]addrWithTags, 0, len(cands.All()))
+	for _, a := range cands.All() {
+		var addr Addr = a
+		tagged = append(tagged, addrWithTags{
+			Addr:     addr,
+			single:   addr == single,
+			fallback: isFallback[addr],
+		})
 	}
-	return addrs, nil
+	return addrList{addrs: tagged, cands: cands}, nil
 }
 
 // ipv4only returns IPv4 addresses that we can use with the kernel's
@@ -268,7 +305,13 @@ func JoinHostPort(host, port string) string {
 // address family addresses when addr is a DNS name and the name has
 // multiple address family records. The result contains at least one
 // address when error is nil.
-func resolveInternetAddrs(net, addr string, deadline time.Time) (addrList, error) {
+//
+// Literal addresses are resolved without consulting resolver at all;
+// resolver.LookupIPAddr is only called for DNS names, under a context
+// bounded by lookupDeadline (see withLookupDeadline and
+// (*Dialer).lookupDeadline) so that a slow or hung resolver can't block
+// past whatever budget the caller gave resolution.
+func resolveInternetAddrs(ctx context.Context, resolver Resolver, net, addr string, lookupDeadline time.Time) (addrList, error) {
 	var (
 		err        error
 		host, port string
@@ -278,10 +321,10 @@ func resolveInternetAddrs(net, addr string, deadline time.Time) (addrList, error
 	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6":
 		if addr != "" {
 			if host, port, err = SplitHostPort(addr); err != nil {
-				return nil, err
+				return addrList{}, err
 			}
 			if portnum, err = parsePort(net, port); err != nil {
-				return nil, err
+				return addrList{}, err
 			}
 		}
 	case "ip", "ip4", "ip6":
@@ -289,7 +332,7 @@ func resolveInternetAddrs(net, addr string, deadline time.Time) (addrList, error
 			host = addr
 		}
 	default:
-		return nil, UnknownNetworkError(net)
+		return addrList{}, UnknownNetworkError(net)
 	}
 	inetaddr := func(ip IPAddr) Addr {
 		switch net {
@@ -316,10 +359,13 @@ func resolveInternetAddrs(net, addr string, deadline time.Time) (addrList, error
 		return makeAddrList(inetaddr(IPAddr{IP: ip, Zone: zone})), nil
 	}
 	// Try as a DNS name.
-	ips, err := lookupIPDeadline(host, deadline)
+	lookupCtx, cancel := withLookupDeadline(ctx, lookupDeadline)
+	defer cancel()
+	ips, err := resolver.LookupIPAddr(lookupCtx, host)
 	if err != nil {
-		return nil, err
+		return addrList{}, err
 	}
+	sortByRFC6724(ips)
 	var filter func(IPAddr) bool
 	if net != "" && net[len(net)-1] == '4' {
 		filter = ipv4only
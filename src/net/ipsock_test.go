@@ -18,7 +18,7 @@ var filterAndTagAddrsTests = []struct {
 	singleAddr Addr
 	primaries  []Addr
 	fallbacks  []Addr
-	err      error
+	err        error
 }{
 	{
 		nil,
@@ -225,8 +225,8 @@ func TestFilterAndTagAddrs(t *testing.T) {
 			t.Errorf("#%v: got err %v; expected %v", i, err, tt.err)
 		}
 		if tt.err != nil {
-			if len(addrs) != 0 {
-				t.Errorf("#%v: got %v addrs, expected 0", len(addrs))
+			if len(addrs.addrs) != 0 {
+				t.Errorf("#%v: got %v addrs, expected 0", i, len(addrs.addrs))
 			}
 			continue
 		}
@@ -249,3 +249,64 @@ func TestFilterAndTagAddrs(t *testing.T) {
 		}
 	}
 }
+
+var interleavedTests = []struct {
+	ips  []IPAddr
+	want []Addr
+}{
+	{
+		// v6 first: alternate, starting with v6.
+		ips: []IPAddr{
+			{IP: IPv6loopback},
+			{IP: ParseIP("fe80::1"), Zone: "eth0"},
+			{IP: IPv4(127, 0, 0, 1)},
+			{IP: IPv4(192, 168, 0, 1)},
+		},
+		want: []Addr{
+			&TCPAddr{IP: IPv6loopback, Port: 5682},
+			&TCPAddr{IP: IPv4(127, 0, 0, 1), Port: 5682},
+			&TCPAddr{IP: ParseIP("fe80::1"), Port: 5682, Zone: "eth0"},
+			&TCPAddr{IP: IPv4(192, 168, 0, 1), Port: 5682},
+		},
+	},
+	{
+		// v4 first: alternate, starting with v4.
+		ips: []IPAddr{
+			{IP: IPv4(127, 0, 0, 1)},
+			{IP: IPv4(192, 168, 0, 1)},
+			{IP: IPv6loopback},
+		},
+		want: []Addr{
+			&TCPAddr{IP: IPv4(127, 0, 0, 1), Port: 5682},
+			&TCPAddr{IP: IPv6loopback, Port: 5682},
+			&TCPAddr{IP: IPv4(192, 168, 0, 1), Port: 5682},
+		},
+	},
+	{
+		// single family: unchanged.
+		ips: []IPAddr{
+			{IP: IPv4(127, 0, 0, 1)},
+			{IP: IPv4(192, 168, 0, 1)},
+		},
+		want: []Addr{
+			&TCPAddr{IP: IPv4(127, 0, 0, 1), Port: 5682},
+			&TCPAddr{IP: IPv4(192, 168, 0, 1), Port: 5682},
+		},
+	},
+}
+
+func TestAddrListInterleaved(t *testing.T) {
+	if !supportsIPv4 || !supportsIPv6 {
+		t.Skip("ipv4 or ipv6 is not supported")
+	}
+	for i, tt := range interleavedTests {
+		addrs, err := filterAndTagAddrs(nil, tt.ips, testInetaddr)
+		if err != nil {
+			t.Fatalf("#%v: filterAndTagAddrs: %v", i, err)
+		}
+		got := addrs.interleaved()
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("#%v: interleaved() = %v; want %v", i, got, tt.want)
+		}
+	}
+}
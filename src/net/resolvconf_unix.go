@@ -0,0 +1,45 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package net
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// systemNameservers returns the "host:port" nameserver addresses listed in
+// /etc/resolv.conf, for use by goResolver when it isn't given an explicit
+// Upstream. Anything it can't parse is silently skipped, matching the
+// leniency of other resolv.conf readers.
+func systemNameservers() []string {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var servers []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if !strings.HasPrefix(line, "nameserver") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		ip := ParseIP(fields[1])
+		if ip == nil {
+			continue
+		}
+		servers = append(servers, JoinHostPort(fields[1], "53"))
+	}
+	return servers
+}
@@ -0,0 +1,57 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"time"
+)
+
+// A Resolver looks up names and services on behalf of a Dialer, or of the
+// package-level Dial functions via DefaultResolver. It exists so that
+// alternative resolution strategies -- the pure Go stub resolver here, or
+// net/dot's DNS-over-TLS/DNS-over-HTTPS resolver -- can be plugged in
+// without reimplementing the Happy Eyeballs address bookkeeping in
+// resolveInternetAddrs.
+type Resolver interface {
+	// LookupIPAddr looks up host and returns its IP addresses, in the
+	// order the resolver received them.
+	LookupIPAddr(ctx context.Context, host string) ([]IPAddr, error)
+
+	// LookupSRV tries to resolve an SRV query of the given service,
+	// protocol and domain name, as specified in RFC 2782. In most cases
+	// proto is "tcp" or "udp". The records need not be sorted; DialSRV
+	// orders them by priority and weight itself.
+	LookupSRV(ctx context.Context, service, proto, name string) (cname string, addrs []*SRV, err error)
+
+	// LookupTXT returns the DNS TXT records for the given domain name.
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DefaultResolver is the Resolver used by a Dialer whose Resolver field is
+// nil, and by the package-level Dial functions. It's a systemResolver,
+// which resolves names through the package's existing lookupIPDeadline,
+// lookupSRV and lookupTXT functions; it doesn't use goResolver.
+var DefaultResolver Resolver = &systemResolver{}
+
+// resolverFor returns the Resolver a Dialer should use: its own, if set,
+// otherwise DefaultResolver.
+func resolverFor(d *Dialer) Resolver {
+	if d != nil && d.Resolver != nil {
+		return d.Resolver
+	}
+	return DefaultResolver
+}
+
+// withLookupDeadline derives a context bounded by deadline from ctx. It
+// lets a Resolver be cancelled on its own schedule -- bounded independently
+// of the overall dial deadline -- while still honoring cancellation of the
+// parent context.
+func withLookupDeadline(ctx context.Context, deadline time.Time) (context.Context, context.CancelFunc) {
+	if deadline.IsZero() {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline)
+}
@@ -0,0 +1,273 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/internal/dnsmsg"
+	"time"
+)
+
+// goResolver is a pure Go DNS stub resolver. It speaks plain DNS over UDP,
+// retrying over TCP when a response comes back truncated, and depends on
+// neither cgo nor any host resolver library -- so it behaves the same on
+// every platform Go supports. DefaultResolver doesn't use it; code in this
+// package can set a *goResolver as a Dialer.Resolver to pin lookups to a
+// specific upstream.
+type goResolver struct {
+	// Upstream is the "host:port" of the recursive resolver to query,
+	// e.g. "8.8.8.8:53". If empty, the servers listed in
+	// /etc/resolv.conf are tried in order.
+	Upstream string
+
+	cache dnsmsg.TTLCache
+}
+
+func (r *goResolver) servers() []string {
+	if r.Upstream != "" {
+		return []string{r.Upstream}
+	}
+	return systemNameservers()
+}
+
+func (r *goResolver) LookupIPAddr(ctx context.Context, host string) ([]IPAddr, error) {
+	var out []IPAddr
+	var lastErr error
+	for _, qtype := range []uint16{dnsTypeA, dnsTypeAAAA} {
+		key := dnsmsg.CacheKey(host, qtype)
+		if v, err, ok := r.cache.Get(key); ok {
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			out = append(out, v.([]IPAddr)...)
+			continue
+		}
+		ips, ttl, err := r.lookupIPs(ctx, host, qtype)
+		if err != nil {
+			lastErr = err
+			r.cache.Put(key, nil, err, dnsmsg.NegativeCacheTTL)
+			continue
+		}
+		out = append(out, ips...)
+		r.cache.Put(key, ips, nil, ttl)
+	}
+	if len(out) == 0 {
+		if lastErr == nil {
+			lastErr = &DNSError{Err: "no such host", Name: host}
+		}
+		return nil, lastErr
+	}
+	return out, nil
+}
+
+// lookupIPs issues a single A or AAAA query and returns the resulting
+// addresses along with the minimum TTL seen across the answer section (for
+// the cache).
+func (r *goResolver) lookupIPs(ctx context.Context, host string, qtype uint16) ([]IPAddr, time.Duration, error) {
+	msg, err := r.exchange(ctx, host, qtype)
+	if err != nil {
+		return nil, 0, err
+	}
+	var out []IPAddr
+	minTTL := time.Duration(1<<63 - 1)
+	for _, rr := range msg.Answer {
+		switch rr.Type {
+		case dnsTypeA:
+			if len(rr.Data) != 4 {
+				continue
+			}
+			out = append(out, IPAddr{IP: IPv4(rr.Data[0], rr.Data[1], rr.Data[2], rr.Data[3])})
+		case dnsTypeAAAA:
+			if len(rr.Data) != 16 {
+				continue
+			}
+			ip := make(IP, 16)
+			copy(ip, rr.Data)
+			out = append(out, IPAddr{IP: ip})
+		default:
+			continue
+		}
+		if ttl := time.Duration(rr.TTL) * time.Second; ttl < minTTL {
+			minTTL = ttl
+		}
+	}
+	if len(out) == 0 {
+		return nil, 0, &DNSError{Err: "no such host", Name: host}
+	}
+	return out, minTTL, nil
+}
+
+func (r *goResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*SRV, error) {
+	target := name
+	if service != "" || proto != "" {
+		target = fmt.Sprintf("_%s._%s.%s", service, proto, name)
+	}
+	msg, err := r.exchange(ctx, target, dnsTypeSRV)
+	if err != nil {
+		return "", nil, err
+	}
+	var srvs []*SRV
+	for _, rr := range msg.Answer {
+		if rr.Type != dnsTypeSRV || len(rr.Data) < 6 {
+			continue
+		}
+		priority := uint16(rr.Data[0])<<8 | uint16(rr.Data[1])
+		weight := uint16(rr.Data[2])<<8 | uint16(rr.Data[3])
+		port := uint16(rr.Data[4])<<8 | uint16(rr.Data[5])
+		tgt, _, err := unpackName(rr.Data, 6)
+		if err != nil {
+			continue
+		}
+		srvs = append(srvs, &SRV{Target: tgt, Port: port, Priority: priority, Weight: weight})
+	}
+	// Ordering the records by RFC 2782 priority/weight is DialSRV's job
+	// (see dialsrv.go); LookupSRV hands back the raw answer order.
+	return target, srvs, nil
+}
+
+func (r *goResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	msg, err := r.exchange(ctx, name, dnsTypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, rr := range msg.Answer {
+		if rr.Type != dnsTypeTXT {
+			continue
+		}
+		var s []byte
+		for i := 0; i < len(rr.Data); {
+			n := int(rr.Data[i])
+			i++
+			if i+n > len(rr.Data) {
+				break
+			}
+			s = append(s, rr.Data[i:i+n]...)
+			i += n
+		}
+		out = append(out, string(s))
+	}
+	return out, nil
+}
+
+// exchange sends a query for name/qtype to the first reachable configured
+// server and returns the parsed response, retrying over TCP if the UDP
+// reply comes back truncated.
+func (r *goResolver) exchange(ctx context.Context, name string, qtype uint16) (*dnsMsg, error) {
+	servers := r.servers()
+	if len(servers) == 0 {
+		return nil, &DNSError{Err: "no DNS servers configured", Name: name}
+	}
+	id := uint16(rand.Intn(1 << 16))
+	query := packQuery(id, name, qtype)
+
+	var lastErr error
+	for _, server := range servers {
+		msg, err := r.exchangeUDP(ctx, server, query, id)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if msg.Truncated {
+			msg, err = r.exchangeTCP(ctx, server, query)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+		}
+		return msg, nil
+	}
+	return nil, lastErr
+}
+
+func (r *goResolver) exchangeUDP(ctx context.Context, server string, query []byte, id uint16) (*dnsMsg, error) {
+	c, err := DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+	}
+	stop := watchContext(ctx, c)
+	defer stop()
+	if _, err := c.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1232) // conservative EDNS-less UDP response size
+	n, err := c.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := unpackMsg(buf[:n])
+	if err != nil {
+		return nil, err
+	}
+	if msg.ID != id {
+		return nil, errDNSMessage
+	}
+	return msg, nil
+}
+
+func (r *goResolver) exchangeTCP(ctx context.Context, server string, query []byte) (*dnsMsg, error) {
+	c, err := DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+	}
+	stop := watchContext(ctx, c)
+	defer stop()
+	lenPrefix := []byte{byte(len(query) >> 8), byte(len(query))}
+	if _, err := c.Write(append(lenPrefix, query...)); err != nil {
+		return nil, err
+	}
+	var lbuf [2]byte
+	if _, err := readFull(c, lbuf[:]); err != nil {
+		return nil, err
+	}
+	msgLen := int(lbuf[0])<<8 | int(lbuf[1])
+	buf := make([]byte, msgLen)
+	if _, err := readFull(c, buf); err != nil {
+		return nil, err
+	}
+	return unpackMsg(buf)
+}
+
+func readFull(c Conn, buf []byte) (int, error) {
+	n := 0
+	for n < len(buf) {
+		m, err := c.Read(buf[n:])
+		if err != nil {
+			return n, err
+		}
+		n += m
+	}
+	return n, nil
+}
+
+// watchContext arranges for c to be closed if ctx is done before stop is
+// called, so a blocking Read/Write on c unblocks with an error instead of
+// hanging past the caller's budget -- including when ctx carries
+// cancellation but no deadline (the common case for a zero-value Dialer;
+// see withLookupDeadline), where nothing else would ever wake up the
+// read. Callers must call the returned stop func once the exchange is
+// done, successful or not, to release the watcher goroutine.
+func watchContext(ctx context.Context, c Conn) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
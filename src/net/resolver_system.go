@@ -0,0 +1,39 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"time"
+)
+
+// systemResolver is the Resolver backing DefaultResolver: it defers to
+// whatever lookup strategy the rest of the package already uses for a
+// given platform, and doesn't go through goResolver. It exists purely as an adapter from the
+// Resolver interface to the package's existing lookupIPDeadline et al.
+// entry points, so that switching DefaultResolver doesn't change behavior
+// for programs that never set Dialer.Resolver.
+type systemResolver struct{}
+
+func (r *systemResolver) LookupIPAddr(ctx context.Context, host string) ([]IPAddr, error) {
+	return lookupIPDeadline(host, deadlineFromContext(ctx))
+}
+
+func (r *systemResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*SRV, error) {
+	return lookupSRV(service, proto, name)
+}
+
+func (r *systemResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return lookupTXT(name)
+}
+
+// deadlineFromContext extracts ctx's deadline, if any, for the legacy
+// lookup* entry points that still take a time.Time instead of a context.
+func deadlineFromContext(ctx context.Context) time.Time {
+	if d, ok := ctx.Deadline(); ok {
+		return d
+	}
+	return time.Time{}
+}